@@ -13,6 +13,7 @@ import (
 	"bytes"
 	"container/list"
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"unicode/utf8"
@@ -28,9 +29,6 @@ type node struct {
 	// be output when matching
 	index int // index into original dictionary if output is true
 
-	counter int // Set to the value of the Matcher.counter when a
-	// match is output to prevent duplicate output
-
 	// The use of fixed size arrays is space-inefficient but fast for
 	// lookups.
 
@@ -49,18 +47,74 @@ type node struct {
 	fail *node // Pointer to the next node which is in the dictionary
 	// which can be reached from here following suffixes. Called fail
 	// because it is used to fallback in the trie when a match fails.
+
+	dictOut []int32 // In heavy mode, the sorted union of fail.dictOut
+	// and this node's own index (if output). Lets MatchAll read off
+	// every dictionary entry matching at this state without walking
+	// the suffix chain. Left nil outside heavy mode.
 }
 
 // Matcher is returned by NewMatcher and contains a list of blices to
-// match against
+// match against. Once built, a *Matcher is read-only and safe for
+// concurrent use by multiple goroutines; per-call state (the current
+// trie position and the set of matches already reported) lives in a
+// caller-owned MatchState instead, see NewState.
 type Matcher struct {
-	counter int // Counts the number of matches done, and is used to
-	// prevent output of multiple matches of the same string
 	trie []node // preallocated block of memory containing all the
 	// nodes
 	extent     int   // offset into trie that is currently free
 	root       *node // Points to trie[0]
 	dictionary [][]byte
+
+	maxLen int // length in bytes of the longest blice in the
+	// dictionary, used to bound how much of the input streaming
+	// readers need to buffer
+
+	heavy bool // true if buildTrie should precompute node.dictOut,
+	// enabling MatchAll. See NewMatcherHeavy.
+
+	mode MatchMode // match mode requested at construction time
+	err  error     // set by buildTrie if mode's requirements were violated
+}
+
+// MatchMode selects how NewMatcher/NewStringMatcher validate their
+// dictionary and, where applicable, how matches are filtered.
+type MatchMode int
+
+const (
+	// MatchModeDefault matches purely on bytes, as the rest of this
+	// package always has.
+	MatchModeDefault MatchMode = iota
+
+	// MatchModeRuneAligned requires the dictionary to be valid UTF-8
+	// and causes Positions to discard any match whose start or end
+	// offset falls inside a UTF-8 continuation byte. This filtering is
+	// only applied by Positions: Match and Replace are unaffected and
+	// will still report byte-level matches that split a rune.
+	MatchModeRuneAligned
+)
+
+// Err returns the error recorded while building m, if any. In
+// MatchModeRuneAligned this is non-nil when the dictionary contained
+// a blice that is not valid UTF-8.
+func (m *Matcher) Err() error {
+	return m.err
+}
+
+// validateMode checks a dictionary against the requirements of mode,
+// returning the first violation found. It is shared by buildTrie and
+// ReadFrom/UnmarshalBinary so a reloaded Matcher ends up with the
+// same Err() as one built fresh with the same mode and dictionary.
+func validateMode(mode MatchMode, dictionary [][]byte) error {
+	if mode != MatchModeRuneAligned {
+		return nil
+	}
+	for _, blice := range dictionary {
+		if !utf8.Valid(blice) {
+			return fmt.Errorf("ahocorasick: dictionary entry %q is not valid UTF-8", blice)
+		}
+	}
+	return nil
 }
 
 // finndBlice looks for a blice in the trie starting from the root and
@@ -101,7 +155,11 @@ func (m *Matcher) buildTrie(dictionary [][]byte) {
 	max := 1
 	for _, blice := range dictionary {
 		max += len(blice)
+		if len(blice) > m.maxLen {
+			m.maxLen = len(blice)
+		}
 	}
+	m.err = validateMode(m.mode, dictionary)
 	m.trie = make([]node, max)
 
 	// Calling this an ignoring its argument simply allocated
@@ -169,6 +227,14 @@ func (m *Matcher) buildTrie(dictionary [][]byte) {
 					c.fail = m.root
 				}
 
+				if m.heavy {
+					c.dictOut = append(c.dictOut, c.fail.dictOut...)
+					if c.output {
+						c.dictOut = append(c.dictOut, int32(c.index))
+					}
+					sort.Slice(c.dictOut, func(x, y int) bool { return c.dictOut[x] < c.dictOut[y] })
+				}
+
 				for j := 1; j < len(c.b); j++ {
 					s := m.findBlice(c.b[j:])
 					if s != nil && s.output {
@@ -195,9 +261,16 @@ func (m *Matcher) buildTrie(dictionary [][]byte) {
 }
 
 // NewMatcher creates a new Matcher used to match against a set of
-// blices
-func NewMatcher(dictionary [][]byte) *Matcher {
+// blices. mode may be omitted for MatchModeDefault, or passed once to
+// select MatchModeRuneAligned; see MatchMode. Note that
+// MatchModeRuneAligned only changes the behavior of
+// (*Matcher).Positions — Match and Replace are byte-oriented
+// regardless of mode.
+func NewMatcher(dictionary [][]byte, mode ...MatchMode) *Matcher {
 	m := new(Matcher)
+	if len(mode) > 0 {
+		m.mode = mode[0]
+	}
 
 	m.buildTrie(dictionary)
 	m.dictionary = dictionary
@@ -205,9 +278,16 @@ func NewMatcher(dictionary [][]byte) *Matcher {
 }
 
 // NewStringMatcher creates a new Matcher used to match against a set
-// of strings (this is a helper to make initialization easy)
-func NewStringMatcher(dictionary []string) *Matcher {
+// of strings (this is a helper to make initialization easy). mode may
+// be omitted for MatchModeDefault, or passed once to select
+// MatchModeRuneAligned; see MatchMode. Note that MatchModeRuneAligned
+// only changes the behavior of (*Matcher).Positions — Match and
+// Replace are byte-oriented regardless of mode.
+func NewStringMatcher(dictionary []string, mode ...MatchMode) *Matcher {
 	m := new(Matcher)
+	if len(mode) > 0 {
+		m.mode = mode[0]
+	}
 
 	var d [][]byte
 	for _, s := range dictionary {
@@ -220,44 +300,14 @@ func NewStringMatcher(dictionary []string) *Matcher {
 }
 
 // Match searches in for blices and returns all the blices found as
-// indexes into the original dictionary
+// indexes into the original dictionary. It is equivalent to driving a
+// fresh MatchState over the whole of in.
 func (m *Matcher) Match(in []byte) []int {
-	m.counter += 1
+	s := m.NewState()
 	var hits []int
 
-	n := m.root
-
 	for _, b := range in {
-		c := int(b)
-
-		if !n.root && n.child[c] == nil {
-			n = n.fails[c]
-		}
-
-		if n.child[c] != nil {
-			f := n.child[c]
-			n = f
-
-			if f.output && f.counter != m.counter {
-				hits = append(hits, f.index)
-				f.counter = m.counter
-			}
-
-			for !f.suffix.root {
-				f = f.suffix
-				if f.counter != m.counter {
-					hits = append(hits, f.index)
-					f.counter = m.counter
-				} else {
-
-					// There's no point working our way up the
-					// suffixes if it's been done before for this call
-					// to Match. The matches are already in hits.
-
-					break
-				}
-			}
-		}
+		hits = append(hits, s.Advance(b)...)
 	}
 
 	return hits
@@ -270,7 +320,8 @@ func (m *Matcher) Replace(inStr string, replacerStr string, isReplace bool, hitT
 	replacer := []byte(replacerStr)
 	var out []byte
 
-	m.counter += 1
+	s := m.NewState()
+	wordSeen := make(map[int]struct{})
 	var hitsWord []string
 	var hitsWordCount map[string]int64
 	var hitsWordIndex map[string][]int64
@@ -293,86 +344,52 @@ func (m *Matcher) Replace(inStr string, replacerStr string, isReplace bool, hitT
 		return "", nil, errors.New("hit type not support")
 	}
 
-	n := m.root
-
 	var bstr string
 
-	for i, b := range in {
-		c := int(b)
-
-		if !n.root && n.child[c] == nil {
-			n = n.fails[c]
-		}
-
-		if n.child[c] != nil {
-
-			f := n.child[c]
-			n = f
-			if f.output && isReplace {
-				hits[i-len(f.b)+1] = f.b
+	recordHitType := func(i int, f *node) {
+		if hitType == EnumHitTypeWord {
+			hitsWord = append(hitsWord, string(f.b))
+		} else if hitType == EnumHitTypeWordCount {
+			bstr = string(f.b)
+			if _, ok := hitsWordCount[bstr]; !ok {
+				hitsWordCount[bstr] = 0
 			}
-			if f.output && (hitType != EnumHitTypeWord || f.counter != m.counter) {
-
-				if hitType == EnumHitTypeWord {
-					hitsWord = append(hitsWord, string(f.b))
-				} else if hitType == EnumHitTypeWordCount {
-					bstr = string(f.b)
-					if _, ok := hitsWordCount[bstr]; !ok {
-						hitsWordCount[bstr] = 0
-					}
-					hitsWordCount[bstr] += 1
-
-				} else if hitType == EnumHitTypeWordIndex {
-					bstr = string(f.b)
+			hitsWordCount[bstr] += 1
 
-					hitsWordIndex[bstr] = append(hitsWordIndex[bstr], int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b)))
-				} else if hitType == EnumHitTypeIndexWord {
+		} else if hitType == EnumHitTypeWordIndex {
+			bstr = string(f.b)
 
-					hitsIndexWord[int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b))] = string(f.b)
-				}
+			hitsWordIndex[bstr] = append(hitsWordIndex[bstr], int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b)))
+		} else if hitType == EnumHitTypeIndexWord {
 
-				f.counter = m.counter
+			hitsIndexWord[int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b))] = string(f.b)
+		}
+	}
 
+	for i, b := range in {
+		for idx, f := range s.advanceRaw(b) {
+			if isReplace {
+				hits[i-len(f.b)+1] = f.b
 			}
-			for !f.suffix.root {
-				f = f.suffix
-				if f.output && isReplace {
-					hits[i-len(f.b)+1] = f.b
-				}
-
-				if hitType != EnumHitTypeWord || f.counter != m.counter {
-					if hitType == EnumHitTypeWord {
-						hitsWord = append(hitsWord, string(f.b))
-					} else if hitType == EnumHitTypeWordCount {
-						bstr = string(f.b)
-						if _, ok := hitsWordCount[bstr]; !ok {
-							hitsWordCount[bstr] = 0
-						}
-						hitsWordCount[bstr] += 1
-
-					} else if hitType == EnumHitTypeWordIndex {
-						bstr = string(f.b)
 
-						hitsWordIndex[bstr] = append(hitsWordIndex[bstr], int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b)))
-					} else if hitType == EnumHitTypeIndexWord {
-
-						hitsIndexWord[int64(utf8.RuneCount(in[:i+1])-utf8.RuneCount(f.b))] = string(f.b)
-					}
-
-					f.counter = m.counter
-
-				} else {
+			if hitType != EnumHitTypeWord {
+				recordHitType(i, f)
+				continue
+			}
 
+			if _, ok := wordSeen[f.index]; ok {
+				if idx > 0 {
 					// There's no point working our way up the
 					// suffixes if it's been done before for this call
-					// to Match. The matches are already in hits.
-
+					// to Replace. The matches are already recorded.
 					break
 				}
+				continue
 			}
 
+			wordSeen[f.index] = struct{}{}
+			recordHitType(i, f)
 		}
-
 	}
 	if isReplace {
 
@@ -0,0 +1,273 @@
+// glob.go: glob-style dictionary patterns built on top of the plain
+// AC matcher, supporting `?` (exactly one byte) and `*` / `*{n,m}`
+// (a run of between n and m bytes; a bare `*` and `*{n,}` are
+// unbounded above, and `*{n}` means exactly n bytes). Gaps are
+// counted in bytes, matching the rest of this
+// package's byte-oriented (blice) design.
+//
+// Each pattern is split at its wildcard boundaries into literal
+// fragments, which are inserted into a single shared trie (so
+// identical fragments across patterns cost nothing extra) tagged with
+// which pattern and fragment index they belong to. Matching then
+// drives that trie once over the input; for each fragment hit it
+// either starts a new in-flight attempt at that pattern (fragment 0)
+// or advances one of the pattern's existing attempts if the gap
+// since its last fragment falls within bounds. A pattern may have
+// several attempts in flight at once — a fresh fragment-0 hit adds a
+// new attempt alongside any already in progress, rather than
+// replacing them, so that an earlier occurrence still within its gap
+// bounds isn't clobbered by a later one before it gets to complete.
+// If an attempt's gap is too short, it is left in place in case a
+// later occurrence of the same literal satisfies it instead; it's
+// only abandoned once the gap grows past the maximum, since no later
+// occurrence could possibly be closer.
+
+package ahocorasick
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GlobOptions configures NewGlobMatcher. It is currently empty and
+// exists so tuning knobs can be added without breaking callers.
+type GlobOptions struct{}
+
+// GlobMatch is a single pattern match reported by GlobMatcher.Match.
+type GlobMatch struct {
+	PatternID int // index into the patterns passed to NewGlobMatcher
+	Start     int // offset of the first byte of the first fragment
+	End       int // offset one past the last byte of the last fragment
+}
+
+// gapSpec bounds the number of bytes a wildcard may consume. max of
+// -1 means unbounded.
+type gapSpec struct {
+	min, max int
+}
+
+func (g gapSpec) allows(n int) bool {
+	return n >= g.min && (g.max < 0 || n <= g.max)
+}
+
+// globFragment tags one literal fragment of one glob pattern.
+type globFragment struct {
+	patternID int
+	fragIdx   int
+	total     int
+	gap       gapSpec // gap preceding this fragment; unused when fragIdx == 0
+}
+
+// GlobMatcher matches a set of glob-style patterns against an input,
+// reporting every pattern that fully matches.
+type GlobMatcher struct {
+	m         *Matcher
+	fragments map[int][]globFragment // dictionary index -> fragments sharing that literal
+}
+
+// NewGlobMatcher compiles patterns, each of which may use `?` to mean
+// exactly one byte and `*` (or `*{n,m}`) to mean a run of bytes, into
+// a GlobMatcher. Patterns with no wildcards take the same fast path
+// as NewStringMatcher, with no per-match bookkeeping beyond a direct
+// report.
+func NewGlobMatcher(patterns []string, opts GlobOptions) (*GlobMatcher, error) {
+	dictIndex := make(map[string]int)
+	var dict [][]byte
+	fragments := make(map[int][]globFragment)
+
+	for pid, pattern := range patterns {
+		literals, gaps, err := parseGlobPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, lit := range literals {
+			key := string(lit)
+			idx, ok := dictIndex[key]
+			if !ok {
+				idx = len(dict)
+				dictIndex[key] = idx
+				dict = append(dict, lit)
+			}
+
+			fragments[idx] = append(fragments[idx], globFragment{
+				patternID: pid,
+				fragIdx:   i,
+				total:     len(literals),
+				gap:       gaps[i],
+			})
+		}
+	}
+
+	return &GlobMatcher{
+		m:         NewMatcher(dict),
+		fragments: fragments,
+	}, nil
+}
+
+// globCandidate is one in-flight match attempt for a pattern. A
+// pattern can have several of these alive at once, one per occurrence
+// of its leading fragment that hasn't yet been completed or ruled
+// out.
+type globCandidate struct {
+	start   int
+	nextIdx int
+	lastEnd int
+}
+
+// Match returns every full match of g's patterns in in, including
+// overlapping matches of the same pattern and of different patterns.
+func (g *GlobMatcher) Match(in []byte) []GlobMatch {
+	s := g.m.NewState()
+	candidates := make(map[int][]globCandidate)
+	var out []GlobMatch
+
+	for i, b := range in {
+		for _, f := range s.advanceRaw(b) {
+			start := i - len(f.b) + 1
+			end := i + 1
+
+			for _, frag := range g.fragments[f.index] {
+				if frag.fragIdx == 0 {
+					if frag.total == 1 {
+						out = append(out, GlobMatch{PatternID: frag.patternID, Start: start, End: end})
+						continue
+					}
+					candidates[frag.patternID] = append(candidates[frag.patternID], globCandidate{start: start, nextIdx: 1, lastEnd: end})
+					continue
+				}
+
+				existing := candidates[frag.patternID]
+				kept := existing[:0]
+				for _, cand := range existing {
+					if cand.nextIdx != frag.fragIdx {
+						kept = append(kept, cand)
+						continue
+					}
+
+					gap := start - cand.lastEnd
+					if !frag.gap.allows(gap) {
+						// Too early: a later occurrence of this same
+						// literal might still land inside the gap, so
+						// leave the candidate in place for it. Too
+						// late: the gap only grows from here, so it
+						// will never be satisfied and the candidate
+						// is stale.
+						if frag.gap.max < 0 || gap <= frag.gap.max {
+							kept = append(kept, cand)
+						}
+						continue
+					}
+
+					cand.nextIdx++
+					cand.lastEnd = end
+					if cand.nextIdx == frag.total {
+						out = append(out, GlobMatch{PatternID: frag.patternID, Start: cand.start, End: end})
+					} else {
+						kept = append(kept, cand)
+					}
+				}
+				candidates[frag.patternID] = kept
+			}
+		}
+	}
+
+	return out
+}
+
+// parseGlobPattern splits pattern at its wildcard boundaries into
+// literal fragments and the gap preceding each one. gaps[0] is
+// computed but never checked, since there is no earlier fragment to
+// measure it from: a leading wildcard's minimum/maximum length is not
+// enforced. Likewise a trailing wildcard with no literal after it is
+// dropped; its length is not enforced either.
+func parseGlobPattern(pattern string) ([][]byte, []gapSpec, error) {
+	var literals [][]byte
+	var gaps []gapSpec
+	var cur []byte
+	var pendingGap *gapSpec
+
+	flush := func() {
+		g := gapSpec{0, 0}
+		if pendingGap != nil {
+			g = *pendingGap
+			pendingGap = nil
+		}
+		gaps = append(gaps, g)
+		literals = append(literals, cur)
+		cur = nil
+	}
+
+	addGap := func(g gapSpec) {
+		if len(cur) > 0 {
+			flush()
+		}
+		if pendingGap == nil {
+			pendingGap = &g
+			return
+		}
+		pendingGap.min += g.min
+		if pendingGap.max < 0 || g.max < 0 {
+			pendingGap.max = -1
+		} else {
+			pendingGap.max += g.max
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '?':
+			addGap(gapSpec{1, 1})
+		case '*':
+			g := gapSpec{0, -1}
+			if i+1 < len(pattern) && pattern[i+1] == '{' {
+				end := strings.IndexByte(pattern[i+1:], '}')
+				if end < 0 {
+					return nil, nil, fmt.Errorf("ahocorasick: unterminated '{' in glob pattern %q", pattern)
+				}
+				var err error
+				g, err = parseGlobBounds(pattern[i+2 : i+1+end])
+				if err != nil {
+					return nil, nil, err
+				}
+				i += end + 1
+			}
+			addGap(g)
+		default:
+			cur = append(cur, pattern[i])
+		}
+	}
+	if len(cur) > 0 {
+		flush()
+	}
+
+	if len(literals) == 0 {
+		return nil, nil, fmt.Errorf("ahocorasick: glob pattern %q has no literal bytes", pattern)
+	}
+	return literals, gaps, nil
+}
+
+// parseGlobBounds parses the "n,m" or "n" inside a `*{n,m}` token. An
+// empty m means unbounded.
+func parseGlobBounds(spec string) (gapSpec, error) {
+	parts := strings.SplitN(spec, ",", 2)
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return gapSpec{}, fmt.Errorf("ahocorasick: invalid glob bound %q", spec)
+	}
+	if len(parts) == 1 {
+		return gapSpec{n, n}, nil
+	}
+
+	mStr := strings.TrimSpace(parts[1])
+	if mStr == "" {
+		return gapSpec{n, -1}, nil
+	}
+	m, err := strconv.Atoi(mStr)
+	if err != nil {
+		return gapSpec{}, fmt.Errorf("ahocorasick: invalid glob bound %q", spec)
+	}
+	return gapSpec{n, m}, nil
+}
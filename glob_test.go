@@ -0,0 +1,78 @@
+package ahocorasick
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGlobMatcherSingleWildcard(t *testing.T) {
+	g, err := NewGlobMatcher([]string{"f?o"}, GlobOptions{})
+	if err != nil {
+		t.Fatalf("NewGlobMatcher: %v", err)
+	}
+
+	got := g.Match([]byte("foo"))
+	if len(got) != 1 {
+		t.Fatalf("Match(%q) = %+v, want one match", "foo", got)
+	}
+	if got[0].Start != 0 || got[0].End != 3 {
+		t.Errorf("Match(%q) = %+v, want Start:0 End:3", "foo", got[0])
+	}
+}
+
+func TestGlobMatcherStarBounded(t *testing.T) {
+	g, err := NewGlobMatcher([]string{"a*{1,3}b"}, GlobOptions{})
+	if err != nil {
+		t.Fatalf("NewGlobMatcher: %v", err)
+	}
+
+	if got := g.Match([]byte("ab")); len(got) != 0 {
+		t.Errorf("Match(%q) = %+v, want no match (gap too short)", "ab", got)
+	}
+	if got := g.Match([]byte("a__b")); len(got) != 1 {
+		t.Errorf("Match(%q) = %+v, want one match", "a__b", got)
+	}
+	if got := g.Match([]byte("a____b")); len(got) != 0 {
+		t.Errorf("Match(%q) = %+v, want no match (gap too long)", "a____b", got)
+	}
+}
+
+func TestGlobMatcherSharedLiteral(t *testing.T) {
+	g, err := NewGlobMatcher([]string{"f?o", "foo"}, GlobOptions{})
+	if err != nil {
+		t.Fatalf("NewGlobMatcher: %v", err)
+	}
+
+	got := g.Match([]byte("foo"))
+	if len(got) != 2 {
+		t.Fatalf("Match(%q) = %+v, want matches for both patterns", "foo", got)
+	}
+}
+
+// TestGlobMatcherRepeatedLiteralBeforeCompletion covers a pattern
+// whose leading literal recurs before the pattern completes: both the
+// earlier and the later occurrence of "foo" can reach a "bar" within
+// gap bounds, and both should be reported rather than the earlier one
+// being clobbered by the later one.
+func TestGlobMatcherRepeatedLiteralBeforeCompletion(t *testing.T) {
+	g, err := NewGlobMatcher([]string{"foo*{0,3}bar"}, GlobOptions{})
+	if err != nil {
+		t.Fatalf("NewGlobMatcher: %v", err)
+	}
+
+	got := g.Match([]byte("foofoobar"))
+	sort.Slice(got, func(i, j int) bool { return got[i].Start < got[j].Start })
+
+	want := []GlobMatch{
+		{PatternID: 0, Start: 0, End: 9},
+		{PatternID: 0, Start: 3, End: 9},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Match(%q) = %+v, want %+v", "foofoobar", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Match(%q)[%d] = %+v, want %+v", "foofoobar", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// heavy.go: an opt-in build mode that trades memory for true
+// O(len(in) + len(result)) match enumeration. Match and Replace find
+// every accepting state at a position by walking the suffix chain,
+// which used to be paired with a Matcher.counter stamped onto each
+// node to avoid reporting the same pattern twice from a single call;
+// that counter is gone (see state.go), but walking the chain is still
+// O(chain length) per byte. Heavy mode instead precomputes, for every
+// trie node, the full set of dictionary entries accepted there, so
+// MatchAll only has to read that precomputed set off the current
+// state.
+
+package ahocorasick
+
+// Occurrence is a single dictionary match reported by MatchAll.
+type Occurrence struct {
+	Index int // index into the original dictionary
+	End   int // offset one past the last byte of the match
+}
+
+// NewMatcherHeavy creates a Matcher like NewMatcher, but additionally
+// precomputes node.dictOut for every state so that MatchAll can be
+// used. This uses more memory than NewMatcher and is only needed when
+// MatchAll is required.
+func NewMatcherHeavy(dict [][]byte) *Matcher {
+	m := new(Matcher)
+	m.heavy = true
+
+	m.buildTrie(dict)
+	m.dictionary = dict
+	return m
+}
+
+// MatchAll reports every occurrence of every dictionary entry in in,
+// including overlapping ones and repeated occurrences of the same
+// entry, in a single O(len(in) + len(result)) pass. It requires m to
+// have been built with NewMatcherHeavy; on a Matcher built with
+// NewMatcher or NewStringMatcher it always returns nil.
+func (m *Matcher) MatchAll(in []byte) []Occurrence {
+	var out []Occurrence
+
+	n := m.root
+	for i, b := range in {
+		c := int(b)
+
+		if !n.root && n.child[c] == nil {
+			n = n.fails[c]
+		}
+		if n.child[c] != nil {
+			n = n.child[c]
+		}
+
+		for _, idx := range n.dictOut {
+			out = append(out, Occurrence{Index: int(idx), End: i + 1})
+		}
+	}
+
+	return out
+}
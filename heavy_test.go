@@ -0,0 +1,57 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMatchAll(t *testing.T) {
+	m := NewMatcherHeavy([][]byte{[]byte("he"), []byte("she"), []byte("his"), []byte("hers")})
+
+	got := m.MatchAll([]byte("ushers"))
+	if len(got) != 3 {
+		t.Fatalf("MatchAll returned %d occurrences, want 3: %+v", len(got), got)
+	}
+}
+
+func TestMatchAllSurvivesPersistRoundTrip(t *testing.T) {
+	m := NewMatcherHeavy([][]byte{[]byte("he"), []byte("she"), []byte("his"), []byte("hers")})
+
+	want := m.MatchAll([]byte("ushers"))
+
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var reloaded Matcher
+	if err := reloaded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	got := reloaded.MatchAll([]byte("ushers"))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MatchAll after round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestPersistRoundTripPlainMatcher(t *testing.T) {
+	m := NewStringMatcher([]string{"cat", "dog"})
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	var reloaded Matcher
+	if _, err := reloaded.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	want := m.Match([]byte("cat and dog"))
+	got := reloaded.Match([]byte("cat and dog"))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Match after round trip = %+v, want %+v", got, want)
+	}
+}
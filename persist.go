@@ -0,0 +1,464 @@
+// persist.go: binary serialization of a compiled Matcher. Building
+// the trie and the fails/suffix tables dominates startup time for
+// large dictionaries, so a Matcher can be serialized once and
+// reloaded later without re-running buildTrie. Node pointers are
+// replaced with varint-encoded node IDs (the node's index into
+// Matcher.trie); root is always node 0. A Matcher built with
+// NewMatcherHeavy also has its heavy flag and per-node dictOut
+// serialized, so MatchAll keeps working after a round trip, and mode
+// is serialized so Positions keeps filtering the same way. Every
+// length and node reference read back by ReadFrom is checked against
+// the trie it's being decoded into, so corrupted input is reported as
+// an error instead of panicking.
+
+package ahocorasick
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// magic identifies the on-disk format so ReadFrom can reject foreign
+// or corrupt data instead of panicking while decoding it.
+const persistMagic uint32 = 0x41434d31 // "ACM1"
+
+// persistVersion is bumped whenever the encoding below changes in a
+// way that isn't backwards compatible.
+//
+// version 2 added the heavy flag and per-node dictOut, so that a
+// Matcher built with NewMatcherHeavy keeps working after a round trip
+// through WriteTo/ReadFrom.
+//
+// version 3 added mode, so that a Matcher built with
+// MatchModeRuneAligned keeps filtering Positions the same way after a
+// round trip through WriteTo/ReadFrom.
+const persistVersion uint16 = 3
+
+// ErrBadMagic is returned by ReadFrom/UnmarshalBinary when the input
+// does not start with the expected header.
+var ErrBadMagic = errors.New("ahocorasick: not a Matcher binary (bad magic)")
+
+// ErrUnsupportedVersion is returned by ReadFrom/UnmarshalBinary when
+// the input was written with a format version other than
+// persistVersion, older or newer.
+var ErrUnsupportedVersion = errors.New("ahocorasick: unsupported Matcher binary version")
+
+// ErrCorrupt is returned by ReadFrom/UnmarshalBinary when the input
+// has the right magic and version but contains a length or node
+// reference that cannot possibly be valid, such as a node reference
+// past the end of the trie. It indicates corrupted or untrusted input
+// rather than an incompatible encoder.
+var ErrCorrupt = errors.New("ahocorasick: corrupt Matcher binary")
+
+// maxPersistNodes and maxPersistDictLen bound the trie size and
+// dictionary length ReadFrom will allocate for a single untrusted
+// length field. Without a bound, a corrupted or malicious extent or
+// dictionary length turns into a huge allocation or a slice-index
+// panic instead of a returned error.
+const (
+	maxPersistNodes   = 1 << 24
+	maxPersistDictLen = 1 << 24
+)
+
+// WriteTo writes a compact binary encoding of m, suitable for caching
+// a prebuilt automaton across process restarts. It implements
+// io.WriterTo.
+func (m *Matcher) WriteTo(w io.Writer) (int64, error) {
+	bw := &countingWriter{w: w}
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	ids := make(map[*node]int, m.extent)
+	for i := 0; i < m.extent; i++ {
+		ids[&m.trie[i]] = i
+	}
+
+	writeUvarint := func(v uint64) error {
+		n := binary.PutUvarint(buf, v)
+		_, err := bw.Write(buf[:n])
+		return err
+	}
+	writeBlice := func(b []byte) error {
+		if err := writeUvarint(uint64(len(b))); err != nil {
+			return err
+		}
+		_, err := bw.Write(b)
+		return err
+	}
+	// nodeRef encodes a possibly-nil *node as id+1, so 0 means nil.
+	nodeRef := func(n *node) uint64 {
+		if n == nil {
+			return 0
+		}
+		return uint64(ids[n]) + 1
+	}
+
+	var hdr [6]byte
+	binary.BigEndian.PutUint32(hdr[0:4], persistMagic)
+	binary.BigEndian.PutUint16(hdr[4:6], persistVersion)
+	if _, err := bw.Write(hdr[:]); err != nil {
+		return bw.n, err
+	}
+
+	heavyFlag := uint64(0)
+	if m.heavy {
+		heavyFlag = 1
+	}
+	if err := writeUvarint(heavyFlag); err != nil {
+		return bw.n, err
+	}
+	if err := writeUvarint(uint64(m.mode)); err != nil {
+		return bw.n, err
+	}
+
+	if err := writeUvarint(uint64(len(m.dictionary))); err != nil {
+		return bw.n, err
+	}
+	for _, blice := range m.dictionary {
+		if err := writeBlice(blice); err != nil {
+			return bw.n, err
+		}
+	}
+
+	if err := writeUvarint(uint64(m.extent)); err != nil {
+		return bw.n, err
+	}
+
+	for i := 0; i < m.extent; i++ {
+		n := &m.trie[i]
+
+		var flags uint64
+		if n.root {
+			flags |= 1
+		}
+		if n.output {
+			flags |= 2
+		}
+		if err := writeUvarint(flags); err != nil {
+			return bw.n, err
+		}
+		if err := writeBlice(n.b); err != nil {
+			return bw.n, err
+		}
+		if n.output {
+			if err := writeUvarint(uint64(n.index)); err != nil {
+				return bw.n, err
+			}
+		}
+		if err := writeUvarint(nodeRef(n.fail)); err != nil {
+			return bw.n, err
+		}
+		if err := writeUvarint(nodeRef(n.suffix)); err != nil {
+			return bw.n, err
+		}
+
+		var nchild uint64
+		for c := 0; c < 256; c++ {
+			if n.child[c] != nil {
+				nchild++
+			}
+		}
+		if err := writeUvarint(nchild); err != nil {
+			return bw.n, err
+		}
+		for c := 0; c < 256; c++ {
+			if n.child[c] != nil {
+				if err := writeUvarint(uint64(c)); err != nil {
+					return bw.n, err
+				}
+				if err := writeUvarint(nodeRef(n.child[c])); err != nil {
+					return bw.n, err
+				}
+			}
+		}
+
+		for c := 0; c < 256; c++ {
+			if err := writeUvarint(nodeRef(n.fails[c])); err != nil {
+				return bw.n, err
+			}
+		}
+
+		if m.heavy {
+			if err := writeUvarint(uint64(len(n.dictOut))); err != nil {
+				return bw.n, err
+			}
+			for _, idx := range n.dictOut {
+				if err := writeUvarint(uint64(idx)); err != nil {
+					return bw.n, err
+				}
+			}
+		}
+	}
+
+	return bw.n, nil
+}
+
+// ReadFrom replaces m's contents with a Matcher previously written by
+// WriteTo. It implements io.ReaderFrom.
+func (m *Matcher) ReadFrom(r io.Reader) (int64, error) {
+	br := &countingReader{r: bufio.NewReader(r)}
+
+	var hdr [6]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return br.n, err
+	}
+	if binary.BigEndian.Uint32(hdr[0:4]) != persistMagic {
+		return br.n, ErrBadMagic
+	}
+	if binary.BigEndian.Uint16(hdr[4:6]) != persistVersion {
+		return br.n, ErrUnsupportedVersion
+	}
+
+	readUvarint := func() (uint64, error) {
+		return binary.ReadUvarint(br)
+	}
+	readBlice := func() ([]byte, error) {
+		l, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if l == 0 {
+			return nil, nil
+		}
+		if l > maxPersistNodes {
+			// A blice this long would need more trie nodes than
+			// ReadFrom accepts.
+			return nil, ErrCorrupt
+		}
+		b := make([]byte, l)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	heavyFlag, err := readUvarint()
+	if err != nil {
+		return br.n, err
+	}
+	heavy := heavyFlag != 0
+
+	modeVal, err := readUvarint()
+	if err != nil {
+		return br.n, err
+	}
+	if modeVal != uint64(MatchModeDefault) && modeVal != uint64(MatchModeRuneAligned) {
+		return br.n, ErrCorrupt
+	}
+	mode := MatchMode(modeVal)
+
+	dictLen, err := readUvarint()
+	if err != nil {
+		return br.n, err
+	}
+	if dictLen > maxPersistDictLen {
+		return br.n, ErrCorrupt
+	}
+	dictionary := make([][]byte, dictLen)
+	for i := range dictionary {
+		b, err := readBlice()
+		if err != nil {
+			return br.n, err
+		}
+		dictionary[i] = b
+	}
+
+	extent, err := readUvarint()
+	if err != nil {
+		return br.n, err
+	}
+	if extent > maxPersistNodes {
+		return br.n, ErrCorrupt
+	}
+
+	trie := make([]node, extent)
+
+	// fail/suffix/child/fails all reference nodes by id (0 means
+	// nil), and those ids may point forward, so they are recorded
+	// here and resolved into pointers once every node exists.
+	type pending struct {
+		fail, suffix uint64
+		child        [256]uint64
+		fails        [256]uint64
+	}
+	refs := make([]pending, extent)
+
+	for i := uint64(0); i < extent; i++ {
+		n := &trie[i]
+
+		flags, err := readUvarint()
+		if err != nil {
+			return br.n, err
+		}
+		n.root = flags&1 != 0
+		n.output = flags&2 != 0
+
+		if n.b, err = readBlice(); err != nil {
+			return br.n, err
+		}
+		if n.output {
+			idx, err := readUvarint()
+			if err != nil {
+				return br.n, err
+			}
+			if idx >= dictLen {
+				return br.n, ErrCorrupt
+			}
+			n.index = int(idx)
+		}
+
+		if refs[i].fail, err = readUvarint(); err != nil {
+			return br.n, err
+		}
+		if refs[i].suffix, err = readUvarint(); err != nil {
+			return br.n, err
+		}
+
+		nchild, err := readUvarint()
+		if err != nil {
+			return br.n, err
+		}
+		if nchild > 256 {
+			return br.n, ErrCorrupt
+		}
+		for j := uint64(0); j < nchild; j++ {
+			c, err := readUvarint()
+			if err != nil {
+				return br.n, err
+			}
+			if c >= 256 {
+				return br.n, ErrCorrupt
+			}
+			ref, err := readUvarint()
+			if err != nil {
+				return br.n, err
+			}
+			refs[i].child[c] = ref
+		}
+
+		for c := 0; c < 256; c++ {
+			if refs[i].fails[c], err = readUvarint(); err != nil {
+				return br.n, err
+			}
+		}
+
+		if heavy {
+			dictOutLen, err := readUvarint()
+			if err != nil {
+				return br.n, err
+			}
+			if dictOutLen > dictLen {
+				return br.n, ErrCorrupt
+			}
+			if dictOutLen > 0 {
+				n.dictOut = make([]int32, dictOutLen)
+				for j := range n.dictOut {
+					v, err := readUvarint()
+					if err != nil {
+						return br.n, err
+					}
+					if v >= dictLen {
+						return br.n, ErrCorrupt
+					}
+					n.dictOut[j] = int32(v)
+				}
+			}
+		}
+	}
+
+	resolve := func(ref uint64) (*node, error) {
+		if ref == 0 {
+			return nil, nil
+		}
+		if ref > extent {
+			return nil, ErrCorrupt
+		}
+		return &trie[ref-1], nil
+	}
+	for i := range trie {
+		if trie[i].fail, err = resolve(refs[i].fail); err != nil {
+			return br.n, err
+		}
+		if trie[i].suffix, err = resolve(refs[i].suffix); err != nil {
+			return br.n, err
+		}
+		for c := 0; c < 256; c++ {
+			if trie[i].child[c], err = resolve(refs[i].child[c]); err != nil {
+				return br.n, err
+			}
+			if trie[i].fails[c], err = resolve(refs[i].fails[c]); err != nil {
+				return br.n, err
+			}
+		}
+	}
+
+	// A Matcher always has at least a root node, and the root is
+	// always node 0 (see getFreeNode).
+	if extent == 0 || !trie[0].root {
+		return br.n, ErrCorrupt
+	}
+
+	m.trie = trie
+	m.extent = int(extent)
+	m.root = &trie[0]
+	m.dictionary = dictionary
+	m.heavy = heavy
+	m.mode = mode
+	m.err = validateMode(mode, dictionary)
+	m.maxLen = 0
+	for _, blice := range dictionary {
+		if len(blice) > m.maxLen {
+			m.maxLen = len(blice)
+		}
+	}
+
+	return br.n, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *Matcher) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It replaces
+// m's contents with the Matcher encoded in b.
+func (m *Matcher) UnmarshalBinary(b []byte) error {
+	_, err := m.ReadFrom(bytes.NewReader(b))
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,119 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalUnmarshalBinary(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "she", "his", "hers"})
+
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var reloaded Matcher
+	if err := reloaded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	want := m.Match([]byte("ushers"))
+	got := reloaded.Match([]byte("ushers"))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Match after round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalBinaryBadMagic(t *testing.T) {
+	var m Matcher
+	err := m.UnmarshalBinary(bytes.Repeat([]byte{0}, 16))
+	if err != ErrBadMagic {
+		t.Fatalf("UnmarshalBinary err = %v, want ErrBadMagic", err)
+	}
+}
+
+func TestPersistPreservesMode(t *testing.T) {
+	// {0xC3} is the lead byte of a two-byte rune; matched against in
+	// it ends one byte short of a rune boundary, so
+	// MatchModeRuneAligned should filter it out of Positions both
+	// before and after a round trip.
+	in := []byte{'a', 0xC3, 0xA9, 'b'}
+	dict := [][]byte{{0xC3}}
+
+	m := NewMatcher(dict, MatchModeRuneAligned)
+	want := m.Positions(in)
+	if len(want) != 0 {
+		t.Fatalf("Positions before round trip = %+v, want none", want)
+	}
+
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var reloaded Matcher
+	if err := reloaded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	got := reloaded.Positions(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Positions after round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadFromRejectsHugeLengths(t *testing.T) {
+	var buf bytes.Buffer
+	var hdr [6]byte
+	binary.BigEndian.PutUint32(hdr[0:4], persistMagic)
+	binary.BigEndian.PutUint16(hdr[4:6], persistVersion)
+	buf.Write(hdr[:])
+
+	vbuf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(v uint64) {
+		n := binary.PutUvarint(vbuf, v)
+		buf.Write(vbuf[:n])
+	}
+	writeUvarint(0)       // heavy flag
+	writeUvarint(0)       // mode
+	writeUvarint(1 << 40) // absurd dictionary length
+
+	var m Matcher
+	if _, err := m.ReadFrom(&buf); err != ErrCorrupt {
+		t.Fatalf("ReadFrom with huge dictionary length err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestReadFromRejectsCorruptRefsWithoutPanicking(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "she", "his", "hers"})
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	// Stomp a trailing window of bytes, where node references live,
+	// so at least one decoded reference points past the end of the
+	// trie. 0x7F is a complete one-byte varint (127), far more than
+	// this trie's 10 nodes. ReadFrom must report that as an error, not
+	// panic.
+	corrupted := append([]byte(nil), b...)
+	for i := len(corrupted) - 20; i < len(corrupted); i++ {
+		corrupted[i] = 0x7F
+	}
+
+	var reloaded Matcher
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("ReadFrom panicked on corrupted input: %v", r)
+			}
+		}()
+		_, err = reloaded.ReadFrom(bytes.NewReader(corrupted))
+	}()
+	if err == nil {
+		t.Fatalf("ReadFrom on corrupted input returned nil error")
+	}
+}
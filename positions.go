@@ -0,0 +1,81 @@
+// positions.go: a position-reporting match API that understands
+// UTF-8 rune boundaries, for dictionaries and inputs that are
+// natural-language text (the original motivation for Replace's
+// utf8.RuneCount calls) rather than arbitrary binary data.
+
+package ahocorasick
+
+// Match is a single dictionary occurrence reported by Positions, with
+// both byte and rune offsets so callers don't have to recompute rune
+// offsets themselves.
+type Match struct {
+	Index     int // index into the original dictionary
+	StartByte int // offset of the first byte of the match
+	EndByte   int // offset one past the last byte of the match
+	StartRune int // rune offset of the first rune of the match
+	EndRune   int // rune offset one past the last rune of the match
+}
+
+// Positions returns every occurrence of every dictionary entry in in,
+// including overlapping ones, with byte and rune offsets. In
+// MatchModeRuneAligned, occurrences whose start or end falls inside a
+// UTF-8 continuation byte are discarded, since they cannot correspond
+// to whole runes of in.
+func (m *Matcher) Positions(in []byte) []Match {
+	boundary := runeBoundaries(in)
+	runeAt := runeIndex(in)
+
+	s := m.NewState()
+	var out []Match
+
+	for i, b := range in {
+		for _, f := range s.advanceRaw(b) {
+			start := i - len(f.b) + 1
+			end := i + 1
+
+			if m.mode == MatchModeRuneAligned && !(boundary[start] && boundary[end]) {
+				continue
+			}
+
+			out = append(out, Match{
+				Index:     f.index,
+				StartByte: start,
+				EndByte:   end,
+				StartRune: runeAt[start],
+				EndRune:   runeAt[end],
+			})
+		}
+	}
+
+	return out
+}
+
+// runeBoundaries reports, for every offset 0..len(in), whether that
+// offset falls on a UTF-8 rune boundary rather than inside a
+// continuation byte.
+func runeBoundaries(in []byte) []bool {
+	boundary := make([]bool, len(in)+1)
+	for i, b := range in {
+		boundary[i] = b&0xC0 != 0x80
+	}
+	boundary[len(in)] = true
+	return boundary
+}
+
+// runeIndex reports, for every offset 0..len(in), the rune index
+// (0-based) of the rune starting there. For valid UTF-8 this is
+// equivalent to but cheaper than calling utf8.RuneCount(in[:offset])
+// once per offset; unlike RuneCount, a stray continuation byte is not
+// counted as a rune of its own.
+func runeIndex(in []byte) []int {
+	runeAt := make([]int, len(in)+1)
+	count := 0
+	for i, b := range in {
+		runeAt[i] = count
+		if b&0xC0 != 0x80 {
+			count++
+		}
+	}
+	runeAt[len(in)] = count
+	return runeAt
+}
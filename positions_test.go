@@ -0,0 +1,42 @@
+package ahocorasick
+
+import "testing"
+
+// TestPositionsRuneAligned builds a dictionary entry that is exactly
+// the lead byte of a two-byte UTF-8 rune ("é" is 0xC3 0xA9). Matched
+// against input containing that rune, the match starts on a rune
+// boundary but ends inside the rune's continuation byte.
+func TestPositionsRuneAligned(t *testing.T) {
+	in := []byte{'a', 0xC3, 0xA9, 'b'} // "a" + é + "b"
+	dict := [][]byte{{0xC3}}
+
+	def := NewMatcher(dict)
+	got := def.Positions(in)
+	if len(got) != 1 {
+		t.Fatalf("default mode: Positions = %+v, want one match", got)
+	}
+
+	aligned := NewMatcher(dict, MatchModeRuneAligned)
+	got = aligned.Positions(in)
+	if len(got) != 0 {
+		t.Fatalf("MatchModeRuneAligned: Positions = %+v, want no matches (ends mid-rune)", got)
+	}
+}
+
+func TestPositionsRuneOffsets(t *testing.T) {
+	in := []byte("a café b") // café has a multi-byte rune
+	m := NewMatcher([][]byte{[]byte("café")})
+
+	got := m.Positions(in)
+	if len(got) != 1 {
+		t.Fatalf("Positions = %+v, want one match", got)
+	}
+
+	match := got[0]
+	if match.StartByte != 2 || match.EndByte != 7 {
+		t.Errorf("byte offsets = %d,%d, want 2,7", match.StartByte, match.EndByte)
+	}
+	if match.StartRune != 2 || match.EndRune != 6 {
+		t.Errorf("rune offsets = %d,%d, want 2,6", match.StartRune, match.EndRune)
+	}
+}
@@ -0,0 +1,218 @@
+// scanner.go: streaming variants of Match/Replace that operate on an
+// io.Reader instead of requiring the whole input to be loaded into
+// memory. The amount of input held in memory is bounded by
+// Matcher.maxLen, the length of the longest blice in the dictionary,
+// rather than by the size of the input.
+
+package ahocorasick
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// ScanMatch describes a single dictionary hit found while scanning a
+// stream, together with its byte offsets in the overall stream.
+type ScanMatch struct {
+	Index int    // index into the original dictionary
+	Start int64  // offset of the first byte of the match
+	End   int64  // offset one past the last byte of the match
+	Word  []byte // the matched blice
+}
+
+// streamState drives a MatchState one byte at a time, tracking how
+// far into the stream the automaton's current position reaches.
+// MatchState.depth() is always the length of the longest suffix of
+// the bytes consumed so far that is present in the trie, so once that
+// suffix shrinks the bytes that fell out of it can never be the start
+// of a later match: pendingStart is therefore a safe watermark below
+// which every match has already been reported.
+type streamState struct {
+	state *MatchState
+	pos   int64 // number of bytes consumed so far
+
+	pendingStart int64 // offset below which no future match can start
+}
+
+func newStreamState(m *Matcher) *streamState {
+	return &streamState{state: m.NewState()}
+}
+
+// advance feeds a single byte through the automaton and returns any
+// matches ending at this byte, longest first followed by its suffix
+// chain. Unlike Match, repeated occurrences of the same dictionary
+// entry are all reported.
+func (s *streamState) advance(b byte) []ScanMatch {
+	hits := s.state.advanceRaw(b)
+	s.pos++
+	s.pendingStart = s.pos - int64(s.state.depth())
+
+	if len(hits) == 0 {
+		return nil
+	}
+
+	matches := make([]ScanMatch, len(hits))
+	for i, f := range hits {
+		matches[i] = ScanMatch{
+			Index: f.index,
+			Start: s.pos - int64(len(f.b)),
+			End:   s.pos,
+			Word:  f.b,
+		}
+	}
+	return matches
+}
+
+// WriteReplacer reads r to completion, writing every byte to w while
+// replacing each dictionary hit with one copy of replacer per rune of
+// the matched word (as Replace does). Input is consumed one byte at a
+// time from a buffered reader, and only the bytes that might still
+// belong to a growing match are held in memory, so buffered input is
+// O(maxPatternLen) rather than O(input); the returned list of matches
+// still grows with the number of matches found.
+// Overlapping matches are resolved greedily in stream order: a match
+// that starts inside a span already replaced is dropped. It returns
+// the number of bytes written to w and the matches found, as
+// []ScanMatch in stream order.
+func (m *Matcher) WriteReplacer(w io.Writer, r io.Reader, replacer []byte) (n int64, hits interface{}, err error) {
+	br := bufio.NewReaderSize(r, 4096)
+	s := newStreamState(m)
+
+	var all []ScanMatch
+	found := make(map[int64][]byte) // start -> word, not yet resolved
+	buf := make([]byte, 0, m.maxLen+1)
+	var lastOut int64 // offset of the next input byte still to emit
+
+	resolve := func(boundary int64) error {
+		var starts []int64
+		for start := range found {
+			if start < boundary {
+				starts = append(starts, start)
+			}
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+		for _, start := range starts {
+			word := found[start]
+			delete(found, start)
+
+			if start < lastOut {
+				// Already covered by an earlier replacement.
+				continue
+			}
+
+			if start > lastOut {
+				gap := int(start - lastOut)
+				if _, werr := w.Write(buf[:gap]); werr != nil {
+					return werr
+				}
+				n += int64(gap)
+				buf = buf[gap:]
+				lastOut = start
+			}
+
+			for i, cnt := 0, utf8.RuneCount(word); i < cnt; i++ {
+				if _, werr := w.Write(replacer); werr != nil {
+					return werr
+				}
+				n += int64(len(replacer))
+			}
+			buf = buf[len(word):]
+			lastOut += int64(len(word))
+		}
+
+		// Bytes between lastOut and boundary belong to no pending
+		// hit and can never start one now, so they can be emitted
+		// as-is.
+		if boundary > lastOut {
+			gap := int(boundary - lastOut)
+			if _, werr := w.Write(buf[:gap]); werr != nil {
+				return werr
+			}
+			n += int64(gap)
+			buf = buf[gap:]
+			lastOut = boundary
+		}
+		return nil
+	}
+
+	for {
+		b, rerr := br.ReadByte()
+		if rerr != nil {
+			if rerr == io.EOF {
+				break
+			}
+			return n, all, rerr
+		}
+
+		matches := s.advance(b)
+		buf = append(buf, b)
+		for _, match := range matches {
+			all = append(all, match)
+			found[match.Start] = match.Word
+		}
+
+		if err := resolve(s.pendingStart); err != nil {
+			return n, all, err
+		}
+	}
+
+	if err := resolve(s.pos); err != nil {
+		return n, all, err
+	}
+
+	return n, all, nil
+}
+
+// MatchScanner yields the dictionary hits found in a stream one at a
+// time, in the style of bufio.Scanner. It is created with
+// (*Matcher).NewScanner.
+type MatchScanner struct {
+	br    *bufio.Reader
+	state *streamState
+	queue []ScanMatch
+	cur   ScanMatch
+	err   error
+}
+
+// NewScanner returns a MatchScanner that reads from r, reporting
+// dictionary hits as they are found without requiring r to be read
+// into memory first.
+func (m *Matcher) NewScanner(r io.Reader) *MatchScanner {
+	return &MatchScanner{
+		br:    bufio.NewReaderSize(r, 4096),
+		state: newStreamState(m),
+	}
+}
+
+// Scan advances the scanner to the next match, returning false when
+// no further matches are available, either because the stream is
+// exhausted or because reading it failed (check Err in that case).
+func (s *MatchScanner) Scan() bool {
+	for len(s.queue) == 0 {
+		b, err := s.br.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+		s.queue = s.state.advance(b)
+	}
+
+	s.cur, s.queue = s.queue[0], s.queue[1:]
+	return true
+}
+
+// Match returns the match found by the most recent call to Scan.
+func (s *MatchScanner) Match() ScanMatch {
+	return s.cur
+}
+
+// Err returns the first non-EOF error encountered while reading the
+// underlying stream, if any.
+func (s *MatchScanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,53 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteReplacerRepeatedMatch(t *testing.T) {
+	m := NewStringMatcher([]string{"cat"})
+
+	var out bytes.Buffer
+	n, hits, err := m.WriteReplacer(&out, strings.NewReader("cat sat cat"), []byte("*"))
+	if err != nil {
+		t.Fatalf("WriteReplacer: %v", err)
+	}
+
+	const want = "*** sat ***"
+	if out.String() != want {
+		t.Errorf("WriteReplacer output = %q, want %q", out.String(), want)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("WriteReplacer n = %d, want %d", n, len(want))
+	}
+
+	matches, ok := hits.([]ScanMatch)
+	if !ok {
+		t.Fatalf("hits is %T, want []ScanMatch", hits)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("len(matches) = %d, want 2", len(matches))
+	}
+}
+
+func TestMatchScannerRepeatedMatch(t *testing.T) {
+	m := NewStringMatcher([]string{"cat"})
+	s := m.NewScanner(strings.NewReader("cat sat cat"))
+
+	var got []ScanMatch
+	for s.Scan() {
+		got = append(got, s.Match())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("len(got) = %d, want 2: %+v", len(got), got)
+	}
+	if got[0].Start != 0 || got[1].Start != 8 {
+		t.Errorf("got starts %d, %d; want 0, 8", got[0].Start, got[1].Start)
+	}
+}
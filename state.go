@@ -0,0 +1,101 @@
+// state.go: per-call matching state, kept separate from *Matcher so
+// that a compiled automaton can be shared across goroutines. Match
+// and node used to track "have I already reported this dictionary
+// entry" by stamping a shared Matcher.counter onto each node, which
+// meant a single *Matcher couldn't be driven by two goroutines at
+// once. MatchState moves that bookkeeping into a struct the caller
+// owns, one per concurrent matching session.
+
+package ahocorasick
+
+// MatchState drives a compiled Matcher byte by byte. It holds the
+// current position in the trie plus the set of dictionary entries
+// already reported, so that repeated occurrences of the same entry
+// are only reported once per MatchState (mirroring the historical
+// behaviour of Match). Create one with (*Matcher).NewState; it is not
+// safe for concurrent use by multiple goroutines, but independent
+// MatchStates over the same Matcher are.
+type MatchState struct {
+	m    *Matcher
+	n    *node
+	seen map[int]struct{}
+}
+
+// NewState returns a MatchState positioned at the root of m, ready to
+// be fed input with Advance.
+func (m *Matcher) NewState() *MatchState {
+	return &MatchState{
+		m:    m,
+		n:    m.root,
+		seen: make(map[int]struct{}),
+	}
+}
+
+// Reset returns s to the root of its Matcher and forgets every
+// dictionary entry reported so far, as if it had just been created by
+// NewState.
+func (s *MatchState) Reset() {
+	s.n = s.m.root
+	for k := range s.seen {
+		delete(s.seen, k)
+	}
+}
+
+// advanceRaw feeds a single byte through the automaton and returns
+// the accepting nodes reached because of it, longest match first
+// followed by its suffix chain, with no deduplication. It updates s.n
+// to the new trie position.
+func (s *MatchState) advanceRaw(b byte) []*node {
+	c := int(b)
+	n := s.n
+
+	if !n.root && n.child[c] == nil {
+		n = n.fails[c]
+	}
+
+	var hits []*node
+
+	if n.child[c] != nil {
+		f := n.child[c]
+		n = f
+
+		if f.output {
+			hits = append(hits, f)
+		}
+		for !f.suffix.root {
+			f = f.suffix
+			hits = append(hits, f)
+		}
+	}
+
+	s.n = n
+	return hits
+}
+
+// depth reports how many bytes of trailing input are represented by
+// s's current trie position (0 at the root). It bounds how much input
+// a streaming reader needs to keep buffered.
+func (s *MatchState) depth() int {
+	if s.n.root {
+		return 0
+	}
+	return len(s.n.b)
+}
+
+// Advance feeds a single byte through the automaton and returns the
+// indexes, into the original dictionary, of entries ending at this
+// byte that have not already been reported by this MatchState since
+// it was created or last Reset.
+func (s *MatchState) Advance(b byte) []int {
+	var hits []int
+	for _, f := range s.advanceRaw(b) {
+		if _, ok := s.seen[f.index]; ok {
+			// Already reported, and so is everything shorter than
+			// it in the suffix chain, by the same earlier call.
+			break
+		}
+		hits = append(hits, f.index)
+		s.seen[f.index] = struct{}{}
+	}
+	return hits
+}
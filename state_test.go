@@ -0,0 +1,61 @@
+package ahocorasick
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMatchStateIndependent(t *testing.T) {
+	m := NewStringMatcher([]string{"he", "she", "his", "hers"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := m.NewState()
+			var got []int
+			for _, b := range []byte("ushers") {
+				got = append(got, s.Advance(b)...)
+			}
+			want := m.Match([]byte("ushers"))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Advance-driven match = %+v, want %+v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMatchStateReset(t *testing.T) {
+	m := NewStringMatcher([]string{"cat"})
+	s := m.NewState()
+
+	for _, b := range []byte("cat") {
+		s.Advance(b)
+	}
+	if got := s.Advance('s'); got != nil {
+		t.Fatalf("Advance('s') after a full match = %v, want nil", got)
+	}
+
+	s.Reset()
+	var got []int
+	for _, b := range []byte("cat") {
+		got = append(got, s.Advance(b)...)
+	}
+	if len(got) != 1 {
+		t.Fatalf("after Reset, Advance over %q = %+v, want one match", "cat", got)
+	}
+}
+
+func TestMatchDedupesWithinACall(t *testing.T) {
+	// Match reports each dictionary entry at most once per call,
+	// mirroring the historical counter-based behaviour it was
+	// refactored from (see MatchState's doc comment).
+	m := NewStringMatcher([]string{"cat"})
+	got := m.Match([]byte("cat sat cat"))
+	if len(got) != 1 {
+		t.Fatalf("Match(%q) = %+v, want 1 hit (deduped)", "cat sat cat", got)
+	}
+}